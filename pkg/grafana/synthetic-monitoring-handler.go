@@ -1,6 +1,7 @@
 package grafana
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -9,17 +10,12 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
-/*
- * @TODO
- * 1. The API does not have a GET method, so we have to fake it here
- * 2. The API expects an ID and a tenantId in an update, but these are
- *    generated by the server so cannot be represented in Jsonnet.
- *    Therefore, we have to pre-retrieve the check to get those values
- *    so we can inject them before posting JSON.
- * 3. This means pre-retrieving the check *twice*, once to establish
- *    whether this resource has changed or not (within Grizzly ifself)
- *    and again within this provider to retrieve IDs. Not ideal.
- */
+// Note: the API does not have a GET method, so GetByUID/GetRemote fake it by
+// listing and filtering. Update reconciles server-owned fields (id,
+// tenantId, created, modified) via grizzly.StrategicMerge, starting from the
+// last-applied manifest that Add and Update record; Prepare/Unprepare
+// are kept for callers that still drive that older flow, built on top of the
+// same ServerOwnedFields schema.
 
 // SyntheticMonitoringHandler is a Grizzly Provider for Grafana Synthetic Monitoring
 type SyntheticMonitoringHandler struct{}
@@ -99,25 +95,53 @@ func (h *SyntheticMonitoringHandler) Parse(m manifest.Manifest) (*grizzly.Resour
 	return &resource, nil
 }
 
-// Unprepare removes unnecessary elements from a remote resource ready for presentation/comparison
+// ServerOwnedFields lists the top-level JSON keys on a check that are
+// populated by the SyntheticMonitoring API itself and never set via
+// Jsonnet/YAML. grizzly.Diff uses this to exclude them from local/remote
+// comparisons, and Prepare/Unprepare below use it instead of a hardcoded list.
+func (h *SyntheticMonitoringHandler) ServerOwnedFields() []string {
+	return []string{"id", "tenantId", "created", "modified"}
+}
+
+// Unprepare removes server-owned elements from a remote resource ready for
+// presentation/comparison. Retained for callers still driving the
+// Prepare/Unprepare flow; Update now reconciles these fields via
+// grizzly.StrategicMerge instead.
 func (h *SyntheticMonitoringHandler) Unprepare(resource grizzly.Resource) *grizzly.Resource {
-	delete(resource.Detail.(Check), "tenantId")
-	delete(resource.Detail.(Check), "id")
-	delete(resource.Detail.(Check), "modified")
-	delete(resource.Detail.(Check), "created")
+	check := resource.Detail.(Check)
+	for _, field := range h.ServerOwnedFields() {
+		delete(check, field)
+	}
 	return &resource
 }
 
-// Prepare gets a resource ready for dispatch to the remote endpoint
+// Prepare copies server-owned elements from the existing remote resource
+// onto the one about to be dispatched. Retained for callers still driving
+// the Prepare/Unprepare flow; Update now reconciles these fields via
+// grizzly.StrategicMerge instead.
 func (h *SyntheticMonitoringHandler) Prepare(existing, resource grizzly.Resource) *grizzly.Resource {
-	resource.Detail.(Check)["tenantId"] = existing.Detail.(Check)["tenantId"]
-	resource.Detail.(Check)["id"] = existing.Detail.(Check)["id"]
+	for _, field := range h.ServerOwnedFields() {
+		resource.Detail.(Check)[field] = existing.Detail.(Check)[field]
+	}
 	return &resource
 }
 
+// ListRemote retrieves the UIDs of all checks present on the endpoint
+func (h *SyntheticMonitoringHandler) ListRemote(ctx context.Context) ([]string, error) {
+	checks, err := getRemoteChecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, 0, len(checks))
+	for _, check := range checks {
+		uids = append(uids, check.UID())
+	}
+	return uids, nil
+}
+
 // GetByUID retrieves JSON for a resource from an endpoint, by UID
-func (h *SyntheticMonitoringHandler) GetByUID(UID string) (*grizzly.Resource, error) {
-	check, err := getRemoteCheck(UID)
+func (h *SyntheticMonitoringHandler) GetByUID(ctx context.Context, UID string) (*grizzly.Resource, error) {
+	check, err := getRemoteCheck(ctx, UID)
 	if err != nil {
 		return nil, fmt.Errorf("Error retrieving check %s: %v", UID, err)
 	}
@@ -135,8 +159,8 @@ func (h *SyntheticMonitoringHandler) GetRepresentation(uid string, resource griz
 }
 
 // GetRemoteRepresentation retrieves a datasource as JSON
-func (h *SyntheticMonitoringHandler) GetRemoteRepresentation(uid string) (string, error) {
-	check, err := getRemoteCheck(uid)
+func (h *SyntheticMonitoringHandler) GetRemoteRepresentation(ctx context.Context, uid string) (string, error) {
+	check, err := getRemoteCheck(ctx, uid)
 	if err != nil {
 		return "", err
 	}
@@ -144,8 +168,8 @@ func (h *SyntheticMonitoringHandler) GetRemoteRepresentation(uid string) (string
 }
 
 // GetRemote retrieves a datasource as a Resource
-func (h *SyntheticMonitoringHandler) GetRemote(uid string) (*grizzly.Resource, error) {
-	check, err := getRemoteCheck(uid)
+func (h *SyntheticMonitoringHandler) GetRemote(ctx context.Context, uid string) (*grizzly.Resource, error) {
+	check, err := getRemoteCheck(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
@@ -154,19 +178,63 @@ func (h *SyntheticMonitoringHandler) GetRemote(uid string) (*grizzly.Resource, e
 }
 
 // Add adds a new check to the SyntheticMonitoring endpoint
-func (h *SyntheticMonitoringHandler) Add(resource grizzly.Resource) error {
+func (h *SyntheticMonitoringHandler) Add(ctx context.Context, resource grizzly.Resource) error {
 	url := getURL("api/v1/check/add")
-	return postCheck(url, newCheck(resource))
+	check := newCheck(resource)
+	if err := postCheck(ctx, url, check); err != nil {
+		return err
+	}
+	modified, err := json.Marshal(resource.Detail)
+	if err != nil {
+		return err
+	}
+	return grizzly.WriteLastApplied(h.Kind(), resource.UID, modified)
 }
 
-// Update pushes an updated check to the SyntheticMonitoring endpoing
-func (h *SyntheticMonitoringHandler) Update(existing, resource grizzly.Resource) error {
-	check := newCheck(resource)
+// Update pushes an updated check to the SyntheticMonitoring endpoint. It
+// three-way-merges the last-applied manifest, the newly modified one, and
+// the current remote check, so server-owned fields like id, tenantId,
+// created and modified survive even though Jsonnet never sets them.
+func (h *SyntheticMonitoringHandler) Update(ctx context.Context, existing, resource grizzly.Resource) error {
+	original, err := grizzly.ReadLastApplied(h.Kind(), resource.UID)
+	if err != nil {
+		return err
+	}
+	modified, err := json.Marshal(resource.Detail)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(existing.Detail)
+	if err != nil {
+		return err
+	}
+	merged, err := grizzly.StrategicMerge(original, modified, current)
+	if err != nil {
+		return err
+	}
+
+	check := Check{}
+	if err := json.Unmarshal(merged, &check); err != nil {
+		return err
+	}
 	url := getURL("api/v1/check/update")
-	return postCheck(url, check)
+	if err := postCheck(ctx, url, check); err != nil {
+		return err
+	}
+	return grizzly.WriteLastApplied(h.Kind(), resource.UID, modified)
 }
 
 // Preview renders Jsonnet then pushes them to the endpoint if previews are possible
-func (h *SyntheticMonitoringHandler) Preview(resource grizzly.Resource, notifier grizzly.Notifier, opts *grizzly.PreviewOpts) error {
+func (h *SyntheticMonitoringHandler) Preview(ctx context.Context, resource grizzly.Resource, notifier grizzly.Notifier, opts *grizzly.PreviewOpts) error {
 	return grizzly.ErrNotImplemented
 }
+
+// getRemoteChecks retrieves every check currently registered on the endpoint
+func getRemoteChecks(ctx context.Context) ([]Check, error) {
+	url := getURL("api/v1/check/list")
+	var checks []Check
+	if err := getJSON(ctx, url, &checks); err != nil {
+		return nil, fmt.Errorf("error listing checks: %v", err)
+	}
+	return checks, nil
+}
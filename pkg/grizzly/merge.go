@@ -0,0 +1,86 @@
+package grizzly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+const lastAppliedDir = ".grizzly/last-applied"
+
+// WriteLastApplied persists the manifest most recently applied for a
+// resource, similar in spirit to kubectl's
+// kubectl.kubernetes.io/last-applied-configuration annotation, so a future
+// StrategicMerge can tell which fields the user actually intended to change.
+func WriteLastApplied(kind, uid string, data []byte) error {
+	dir := filepath.Join(lastAppliedDir, kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, uid+".json"), data, 0644)
+}
+
+// ReadLastApplied returns the last-applied manifest for a resource, or nil
+// if none has been recorded yet, e.g. on first apply.
+func ReadLastApplied(kind, uid string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(lastAppliedDir, kind, uid+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// StrategicMerge computes the patch of user-intended changes (modified minus
+// original) and applies only those keys on top of current. Keys that the
+// user never touched - typically server-populated fields like id, tenantId,
+// created or modified - are left as they are in current, so providers no
+// longer need per-handler Prepare/Unprepare logic to re-inject them before
+// dispatch.
+func StrategicMerge(original, modified, current []byte) ([]byte, error) {
+	o := map[string]interface{}{}
+	if len(original) > 0 {
+		if err := json.Unmarshal(original, &o); err != nil {
+			return nil, fmt.Errorf("error parsing original: %v", err)
+		}
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(modified, &m); err != nil {
+		return nil, fmt.Errorf("error parsing modified: %v", err)
+	}
+
+	c := map[string]interface{}{}
+	if err := json.Unmarshal(current, &c); err != nil {
+		return nil, fmt.Errorf("error parsing current: %v", err)
+	}
+
+	for k := range o {
+		if _, stillPresent := m[k]; !stillPresent {
+			delete(c, k)
+		}
+	}
+	for k, mv := range m {
+		if ov, existed := o[k]; !existed || !reflect.DeepEqual(ov, mv) {
+			c[k] = mv
+		}
+	}
+
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// StripFields removes top-level keys from a JSON object, re-marshalling the
+// result. It's used to exclude a provider's declared server-owned fields
+// (see ServerOwnedFields) from local/remote comparisons during Diff.
+func StripFields(data []byte, fields []string) ([]byte, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing resource: %v", err)
+	}
+	for _, field := range fields {
+		delete(m, field)
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
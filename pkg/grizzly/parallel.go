@@ -0,0 +1,103 @@
+package grizzly
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrRateLimited should be returned by a task (ultimately, by the Grafana
+// API client) when a request was rejected by the remote's rate limiter.
+// runParallel retries such tasks with exponential backoff instead of
+// counting them as a failure straight away.
+var ErrRateLimited = errors.New("rate limited")
+
+const (
+	maxRateLimitRetries = 3
+	rateLimitBaseDelay  = 250 * time.Millisecond
+)
+
+// runWithBackoff retries task with exponential backoff while it returns
+// ErrRateLimited, giving up after maxRateLimitRetries attempts or if ctx is
+// cancelled first.
+func runWithBackoff(ctx context.Context, task func() error) error {
+	var err error
+	for attempt := 0; attempt < maxRateLimitRetries; attempt++ {
+		err = task()
+		if !errors.Is(err, ErrRateLimited) {
+			return err
+		}
+		delay := rateLimitBaseDelay * time.Duration(1<<attempt)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// runParallel dispatches one task per resource across parallelism goroutines,
+// showing a progress bar when stdout is a TTY and falling back to the
+// existing per-line output otherwise. Errors from individual tasks are
+// collected rather than aborting the run, and returned together as a single
+// multi-error. If ctx is cancelled, no further jobs are dispatched and the
+// error returned includes ctx.Err() alongside any errors already collected.
+// Tasks that report ErrRateLimited are retried with backoff rather than
+// failed immediately; task functions calling into a Provider must therefore
+// be safe to invoke more than once and safe for concurrent invocation across
+// goroutines.
+func runParallel(ctx context.Context, resources Resources, parallelism int, task func(resource Resource) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var bar *pb.ProgressBar
+	if interactive {
+		bar = pb.StartNew(len(resources))
+		defer bar.Finish()
+	}
+
+	jobs := make(chan Resource)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var result error
+
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for resource := range jobs {
+				err := runWithBackoff(ctx, func() error { return task(resource) })
+				if bar != nil {
+					bar.Increment()
+				}
+				if err != nil {
+					mu.Lock()
+					result = multierror.Append(result, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, resource := range resources {
+		select {
+		case jobs <- resource:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return multierror.Append(result, ctx.Err())
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package grizzly
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Pull retrieves every remote resource known to each registered Provider and
+// writes those matching targets to outputDir/<Kind>/<uid>.json, seeding a
+// local repo from an existing Grafana instance.
+func Pull(ctx context.Context, config Config, outputDir string, targets []string) error {
+	for _, provider := range config.Registry.ProviderList {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		uids, err := provider.ListRemote(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing remote resources for %s: %v", provider.GetName(), err)
+		}
+
+		for _, uid := range uids {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			resource, err := provider.GetByUID(ctx, uid)
+			if err != nil {
+				return fmt.Errorf("error retrieving %s %s: %v", provider.GetName(), uid, err)
+			}
+			if !resource.MatchesTarget(targets) {
+				continue
+			}
+
+			rep, err := resource.GetRepresentation()
+			if err != nil {
+				return err
+			}
+
+			dir := fmt.Sprintf("%s/%s", outputDir, resource.Kind())
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return err
+				}
+			}
+
+			path := fmt.Sprintf("%s/%s.json", dir, uid)
+			if err := ioutil.WriteFile(path, []byte(rep), 0644); err != nil {
+				return err
+			}
+			fmt.Println(uid, Green("pulled"))
+		}
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package grizzly
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"text/tabwriter"
 
 	"github.com/google/go-jsonnet"
@@ -18,8 +23,26 @@ import (
 
 var interactive = terminal.IsTerminal(int(os.Stdout.Fd()))
 
+// NewCancelableContext returns a context that is cancelled when the process
+// receives SIGINT or SIGTERM, so long-running commands like Watch and the
+// parallel Apply can shut down cleanly instead of leaving in-flight requests
+// orphaned.
+func NewCancelableContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // Get retrieves JSON for a dashboard from Grafana, using the dashboard's UID
-func Get(config Config, UID string) error {
+func Get(ctx context.Context, config Config, UID string) error {
 	if !strings.Contains(UID, ".") {
 		return fmt.Errorf("UID must be <provider>.<uid>: %s", UID)
 	}
@@ -32,7 +55,7 @@ func Get(config Config, UID string) error {
 		return err
 	}
 
-	resource, err := provider.GetByUID(id)
+	resource, err := provider.GetByUID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -47,7 +70,7 @@ func Get(config Config, UID string) error {
 }
 
 // List outputs the keys of the grafanaDashboards object.
-func List(config Config, jsonnetFile string) error {
+func List(ctx context.Context, config Config, jsonnetFile string) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
@@ -80,6 +103,9 @@ func getPrivateElementsScript(jsonnetFile string, providers []Provider) string {
 }
 
 func parse(config Config, jsonnetFile string) (Resources, error) {
+	if isManifestPath(jsonnetFile) {
+		return parseManifests(config, jsonnetFile)
+	}
 
 	script := getPrivateElementsScript(jsonnetFile, config.Registry.ProviderList)
 	vm := jsonnet.MakeVM()
@@ -115,7 +141,7 @@ func parse(config Config, jsonnetFile string) (Resources, error) {
 }
 
 // Show renders a Jsonnet dashboard as JSON, consuming a jsonnet filename
-func Show(config Config, jsonnetFile string, targets []string) error {
+func Show(ctx context.Context, config Config, jsonnetFile string, targets []string) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
@@ -144,65 +170,107 @@ func Show(config Config, jsonnetFile string, targets []string) error {
 	return nil
 }
 
-// Diff renders Jsonnet resources and compares them to those at the endpoints
-func Diff(config Config, jsonnetFile string, targets []string) error {
+// serverOwnedFieldsProvider is implemented by providers that declare a merge
+// schema of server-owned JSON keys (e.g. id, tenantId, created, modified) to
+// exclude from local/remote comparisons, so applying a resource they've
+// never touched doesn't show as permanent drift. Providers that don't
+// implement it are compared as-is.
+type serverOwnedFieldsProvider interface {
+	ServerOwnedFields() []string
+}
+
+// Diff renders Jsonnet resources and compares them to those at the
+// endpoints. In interactive mode the per-resource results are collected and
+// printed once the progress bar completes, rather than being dropped.
+func Diff(ctx context.Context, config Config, jsonnetFile string, targets []string, parallelism int) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
 	}
 
-	for _, resource := range resources {
+	var mu sync.Mutex
+	var buffered []string
+	emit := func(line string) {
+		if interactive {
+			mu.Lock()
+			buffered = append(buffered, line)
+			mu.Unlock()
+			return
+		}
+		fmt.Println(line)
+	}
+
+	runErr := runParallel(ctx, resources, parallelism, func(resource Resource) error {
 		local, err := resource.GetRepresentation()
 		if err != nil {
-			return nil
+			return err
 		}
 		uid := resource.UID
-		remote, err := resource.GetRemoteRepresentation()
+		remote, err := resource.GetRemoteRepresentation(ctx)
 		if err == ErrNotFound {
-			log.Println(uid, Yellow("not present in "+resource.Kind()))
-			continue
+			emit(fmt.Sprintf("%s %s", uid, Yellow("not present in "+resource.Kind())))
+			return nil
 		}
 		if err != nil {
 			return fmt.Errorf("Error retrieving resource from %s %s: %v", resource.Kind(), uid, err)
 		}
 
+		if fp, ok := resource.Provider.(serverOwnedFieldsProvider); ok {
+			if fields := fp.ServerOwnedFields(); len(fields) > 0 {
+				stripped, err := StripFields([]byte(remote), fields)
+				if err != nil {
+					return err
+				}
+				remote = string(stripped)
+			}
+		}
+
 		if local == remote {
-			fmt.Println(uid, Yellow("no differences"))
+			emit(fmt.Sprintf("%s %s", uid, Yellow("no differences")))
 		} else {
-			fmt.Println(uid, Red("changes detected:"))
-			difference := diff.Diff(remote, local)
-			fmt.Println(difference)
+			emit(fmt.Sprintf("%s %s\n%s", uid, Red("changes detected:"), diff.Diff(remote, local)))
 		}
+		return nil
+	})
+
+	for _, line := range buffered {
+		fmt.Println(line)
 	}
-	return nil
+	return runErr
 }
 
-// Apply renders Jsonnet dashboards then pushes them to Grafana via the API
-func Apply(config Config, jsonnetFile string, targets []string) error {
+// Apply renders Jsonnet dashboards then pushes them to Grafana via the API,
+// dispatching up to parallelism resources concurrently.
+func Apply(ctx context.Context, config Config, jsonnetFile string, targets []string, parallelism int) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
 	}
-	for _, resource := range resources {
+
+	targeted := Resources{}
+	for k, resource := range resources {
 		if resource.MatchesTarget(targets) {
-			err := resource.Provider.Apply(resource.Detail)
-			if err != nil {
-				return err
-			}
+			targeted[k] = resource
 		}
 	}
-	return nil
+
+	return runParallel(ctx, targeted, parallelism, func(resource Resource) error {
+		return resource.Provider.Apply(ctx, resource.Detail)
+	})
 }
 
 // Preview renders Jsonnet dashboards then pushes them to Grafana via the Snapshot API
-func Preview(config Config, jsonnetFile string, targets []string, opts *PreviewOpts) error {
+func Preview(ctx context.Context, config Config, jsonnetFile string, targets []string, opts *PreviewOpts) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
 	}
 	for _, resource := range resources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if resource.MatchesTarget(targets) {
-			err := resource.Provider.Preview(resource.Detail)
+			err := resource.Provider.Preview(ctx, resource.Detail)
 			if err != nil {
 				return err
 			}
@@ -211,16 +279,17 @@ func Preview(config Config, jsonnetFile string, targets []string, opts *PreviewO
 	return nil
 }
 
-// Watch watches a directory for changes then pushes Jsonnet dashboards to Grafana
-// when changes are noticed
-func Watch(config Config, watchDir, jsonnetFile string, targets []string) error {
+// Watch watches a directory for changes then pushes Jsonnet dashboards to
+// Grafana when changes are noticed. It runs until ctx is cancelled, at which
+// point it prints a summary of what was applied before shutting down.
+func Watch(ctx context.Context, config Config, watchDir, jsonnetFile string, targets []string) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 	defer watcher.Close()
 
-	done := make(chan bool)
+	var applied int64
 	go func() {
 		for {
 			select {
@@ -237,11 +306,12 @@ func Watch(config Config, watchDir, jsonnetFile string, targets []string) error
 					}
 					for _, resource := range resources {
 						if resource.MatchesTarget(targets) {
-							err := resource.Provider.Apply(resource.Detail)
+							err := resource.Provider.Apply(ctx, resource.Detail)
 							if err != nil {
 								log.Println("error:", err)
 								continue
 							}
+							atomic.AddInt64(&applied, 1)
 						}
 					}
 				}
@@ -250,6 +320,8 @@ func Watch(config Config, watchDir, jsonnetFile string, targets []string) error
 					return
 				}
 				log.Println("error:", err)
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
@@ -258,12 +330,14 @@ func Watch(config Config, watchDir, jsonnetFile string, targets []string) error
 	if err != nil {
 		return err
 	}
-	<-done
-	return nil
+	<-ctx.Done()
+	log.Printf("aborted: %d resource(s) applied before cancellation", atomic.LoadInt64(&applied))
+	return ctx.Err()
 }
 
-// Export renders Jsonnet dashboards then saves them to a directory
-func Export(config Config, jsonnetFile, dashboardDir string, targets []string) error {
+// Export renders Jsonnet dashboards then saves them to a directory,
+// dispatching up to parallelism resources concurrently.
+func Export(ctx context.Context, config Config, jsonnetFile, dashboardDir string, targets []string, parallelism int) error {
 	resources, err := parse(config, jsonnetFile)
 	if err != nil {
 		return err
@@ -274,36 +348,46 @@ func Export(config Config, jsonnetFile, dashboardDir string, targets []string) e
 			return err
 		}
 	}
-	for _, resource := range resources {
+
+	targeted := Resources{}
+	for k, resource := range resources {
 		if resource.MatchesTarget(targets) {
-			updatedResource, err := resource.GetRepresentation()
-			if err != nil {
-				return err
-			}
-			uid := resource.UID
-			extension := resource.Provider.GetExtension()
 			dir := fmt.Sprintf("%s/%s", dashboardDir, resource.Kind())
 			if _, err := os.Stat(dir); os.IsNotExist(err) {
-				err = os.Mkdir(dir, 0755)
-				if err != nil {
+				if err := os.Mkdir(dir, 0755); err != nil {
 					return err
 				}
 			}
-			path := fmt.Sprintf("%s/%s.%s", dir, resource.UID, extension)
+			targeted[k] = resource
+		}
+	}
 
-			existingResourceBytes, err := ioutil.ReadFile(path)
-			isNotExist := os.IsNotExist(err)
-			if err != nil && !isNotExist {
+	return runParallel(ctx, targeted, parallelism, func(resource Resource) error {
+		updatedResource, err := resource.GetRepresentation()
+		if err != nil {
+			return err
+		}
+		uid := resource.UID
+		extension := resource.Provider.GetExtension()
+		dir := fmt.Sprintf("%s/%s", dashboardDir, resource.Kind())
+		path := fmt.Sprintf("%s/%s.%s", dir, resource.UID, extension)
+
+		existingResourceBytes, err := ioutil.ReadFile(path)
+		isNotExist := os.IsNotExist(err)
+		if err != nil && !isNotExist {
+			return err
+		}
+		existingResource := string(existingResourceBytes)
+		if existingResource == updatedResource {
+			if !interactive {
+				fmt.Println(uid, Yellow("unchanged"))
+			}
+		} else {
+			err = ioutil.WriteFile(path, []byte(updatedResource), 0644)
+			if err != nil {
 				return err
 			}
-			existingResource := string(existingResourceBytes)
-			if existingResource == updatedResource {
-				fmt.Println(uid, Yellow("unchanged"))
-			} else {
-				err = ioutil.WriteFile(path, []byte(updatedResource), 0644)
-				if err != nil {
-					return err
-				}
+			if !interactive {
 				if isNotExist {
 					fmt.Println(uid, Green("added"))
 				} else {
@@ -311,6 +395,6 @@ func Export(config Config, jsonnetFile, dashboardDir string, targets []string) e
 				}
 			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,105 @@
+package grizzly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"sigs.k8s.io/yaml"
+)
+
+// isManifestPath returns true if path looks like a YAML/JSON manifest source
+// rather than a Jsonnet entrypoint.
+func isManifestPath(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// parseManifests reads a directory or file of YAML/JSON manifests and
+// dispatches each document to the Provider matching its apiVersion and kind,
+// mirroring the jsonnet-backed parse() in workflow.go.
+func parseManifests(config Config, path string) (Resources, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := Resources{}
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, doc := range strings.Split(string(contents), "\n---\n") {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+
+			msi := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(doc), &msi); err != nil {
+				return nil, fmt.Errorf("error parsing %s: %v", file, err)
+			}
+			if len(msi) == 0 {
+				continue
+			}
+
+			apiVersion, ok := msi["apiVersion"].(string)
+			if !ok {
+				return nil, fmt.Errorf("error parsing %s: missing or non-string apiVersion", file)
+			}
+			kind, ok := msi["kind"].(string)
+			if !ok {
+				return nil, fmt.Errorf("error parsing %s: missing or non-string kind", file)
+			}
+
+			m := manifest.Manifest(msi)
+			provider, err := config.Registry.GetProviderFor(apiVersion, kind)
+			if err != nil {
+				return nil, err
+			}
+
+			resource, err := provider.Parse(m)
+			if err != nil {
+				return nil, err
+			}
+			r[resource.Key()] = *resource
+		}
+	}
+	return r, nil
+}
+
+// manifestFiles expands path into the list of files to read: the file itself,
+// or every .yaml/.yml/.json file in the directory tree rooted at path.
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(p) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}